@@ -0,0 +1,136 @@
+package schemastream
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// defaultMaxDepth bounds how deeply decodeAnything will recurse into nested
+// objects/arrays before giving up, so a maliciously or accidentally deeply
+// nested document can't blow the stack.
+const defaultMaxDepth = 10000
+
+// maxDepthOrDefault returns configured, or defaultMaxDepth if it's unset.
+func maxDepthOrDefault(configured int) int {
+	if configured == 0 {
+		return defaultMaxDepth
+	}
+	return configured
+}
+
+// Logger receives optional trace output from the decoder, one line per
+// token/object/array it decodes. A nil Logger (the default, used when
+// Options.Logger is unset) disables tracing entirely.
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// UnknownPropertyMode controls what decodeObject does when it encounters an
+// object key that matches none of schema.Properties, PatternProperties, or
+// AdditionalProperties.
+type UnknownPropertyMode int
+
+const (
+	// ErrorOnUnknown fails decoding with an UnknownPropertyError. This is
+	// the default (the zero value of UnknownPropertyMode).
+	ErrorOnUnknown UnknownPropertyMode = iota
+	// WarnOnUnknown logs the unknown property via Options.Logger, then
+	// decodes and discards its value.
+	WarnOnUnknown
+	// SkipUnknown silently decodes and discards the unknown property's
+	// value.
+	SkipUnknown
+)
+
+// UnknownPropertyError reports a JSON object key with no matching schema
+// property, pattern, or additionalProperties definition. Pointer is the
+// JSON Pointer (RFC 6901) to the offending key.
+type UnknownPropertyError struct {
+	Pointer string
+}
+
+func (e *UnknownPropertyError) Error() string {
+	return fmt.Sprintf("unknown property at %s", e.Pointer)
+}
+
+// MaxDepthError reports that decoding exceeded Options.MaxDepth.
+type MaxDepthError struct {
+	MaxDepth int
+	Pointer  string
+}
+
+func (e *MaxDepthError) Error() string {
+	return fmt.Sprintf("exceeded max depth %d at %s", e.MaxDepth, e.Pointer)
+}
+
+// pointerEscaper escapes "~" and "/" inside a JSON Pointer reference token.
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer builds the RFC 6901 JSON Pointer for key at path.
+func jsonPointer(path []string, key string) string {
+	segments := append(append([]string{}, path...), key)
+	for i, segment := range segments {
+		segments[i] = pointerEscaper.Replace(segment)
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// Options configures a ValidateParse*Options call. The zero value is the
+// original ValidateParse behaviour: no discriminator types registered, a depth limit
+// of defaultMaxDepth, unknown properties rejected outright, and no tracing.
+type Options struct {
+	// Types registers the concrete Go types to allocate for an interface{}
+	// destination when decoding a discriminated oneOf/anyOf schema, keyed
+	// by discriminator value.
+	Types map[string]reflect.Type
+	// MaxDepth bounds nesting depth; 0 means defaultMaxDepth.
+	MaxDepth int
+	// UnknownProperty controls handling of object keys absent from the
+	// schema.
+	UnknownProperty UnknownPropertyMode
+	// Logger, if set, receives trace output for every token decoded.
+	Logger Logger
+}
+
+// validateStructTags checks t (a struct, or pointer to one) for duplicate or
+// malformed `json` tags, so a typo in a struct tag fails fast at
+// ValidateParse entry instead of silently breaking field resolution deep
+// inside decodeObject's fieldsByTag.
+func validateStructTags(t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		tag, ok := t.Field(i).Tag.Lookup("json")
+		if !ok {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		name := parts[0]
+		for _, opt := range parts[1:] {
+			switch opt {
+			case "omitempty", "string":
+			case "":
+				return fmt.Errorf("malformed json tag %q on field %s", tag, t.Field(i).Name)
+			default:
+				return fmt.Errorf("unknown json tag option %q on field %s", opt, t.Field(i).Name)
+			}
+		}
+
+		if name == "" || name == "-" {
+			continue
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate json tag %q on type %s", name, t.Name())
+		}
+		seen[name] = true
+	}
+	return nil
+}
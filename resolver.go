@@ -0,0 +1,111 @@
+package schemastream
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// SchemaResolver resolves $ref pointers found within a schema tree back to
+// the concrete *spec.Schema they point at. Local pointers ("#/definitions/Foo"
+// or "#/$defs/Foo") are resolved against root; anything else is handed to
+// loader. Every resolution is cached by its $ref string, so a ref found
+// inside a streamed array's item schema is only walked once rather than once
+// per element.
+type SchemaResolver struct {
+	root   *spec.Schema
+	loader func(uri string) (*spec.Schema, error)
+	cache  map[string]*spec.Schema
+}
+
+// NewResolver builds a SchemaResolver rooted at root. loader is consulted for
+// any $ref that isn't a bare "#/..." fragment against root, and may be nil if
+// the schema tree has no external references.
+func NewResolver(root *spec.Schema, loader func(uri string) (*spec.Schema, error)) *SchemaResolver {
+	return &SchemaResolver{
+		root:   root,
+		loader: loader,
+		cache:  map[string]*spec.Schema{},
+	}
+}
+
+// Resolve follows schema.Ref, if set, through to the schema it ultimately
+// points at, chasing chained refs and erroring on cycles. A schema with an
+// empty Ref is returned unchanged.
+func (r *SchemaResolver) Resolve(schema *spec.Schema) (*spec.Schema, error) {
+	return r.resolve(schema, map[string]bool{})
+}
+
+func (r *SchemaResolver) resolve(schema *spec.Schema, seen map[string]bool) (*spec.Schema, error) {
+	if schema == nil {
+		return nil, nil
+	}
+
+	ref := schema.Ref.String()
+	if ref == "" {
+		return schema, nil
+	}
+
+	if seen[ref] {
+		return nil, fmt.Errorf("Cyclical $ref at %s", ref)
+	}
+	seen[ref] = true
+
+	if cached, ok := r.cache[ref]; ok {
+		return cached, nil
+	}
+
+	resolved, err := r.lookup(ref)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Resolving $ref %s", ref)
+	}
+
+	resolved, err = r.resolve(resolved, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	r.cache[ref] = resolved
+	return resolved, nil
+}
+
+func (r *SchemaResolver) lookup(ref string) (*spec.Schema, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Path == "" && u.Host == "" {
+		return r.lookupLocal(u.Fragment)
+	}
+
+	if r.loader == nil {
+		return nil, fmt.Errorf("no loader configured to resolve external $ref %s", ref)
+	}
+
+	return r.loader(ref)
+}
+
+// lookupLocal resolves a local JSON pointer fragment such as
+// "/definitions/Foo" or "/$defs/Foo" against root.
+func (r *SchemaResolver) lookupLocal(fragment string) (*spec.Schema, error) {
+	parts := strings.Split(strings.Trim(fragment, "/"), "/")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("unsupported local $ref fragment %s", fragment)
+	}
+
+	section, name := parts[0], parts[1]
+	switch section {
+	case "definitions", "$defs":
+		schema, ok := r.root.Definitions[name]
+		if !ok {
+			return nil, fmt.Errorf("no definition named %s", name)
+		}
+		return &schema, nil
+	default:
+		return nil, fmt.Errorf("unsupported local $ref section %s", section)
+	}
+}
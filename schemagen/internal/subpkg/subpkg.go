@@ -0,0 +1,11 @@
+// Package subpkg exists only to give schemagen's tests a second Item type
+// that shares a bare name with one declared in the test package itself, so
+// package-qualified Definitions keys can be exercised against a genuine
+// cross-package name collision.
+package subpkg
+
+// Item deliberately shares its bare name with a type declared in
+// schemagen_test.go.
+type Item struct {
+	Count int `json:"count"`
+}
@@ -0,0 +1,345 @@
+// Package schemagen generates *spec.Schema values from Go types, so callers
+// of schemastream.ValidateParse don't have to hand-author a JSON schema
+// alongside the struct it decodes into. The generated schema follows the
+// exact field-resolution rules schemastream's decoder uses: a field's schema
+// property is keyed by its `json` tag, falling back to the lower-cased Go
+// field name when no tag is present (mirroring decodeObject's fieldsByTag /
+// backupFieldsByTag).
+package schemagen
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// Generator builds schemas from reflect.Types, memoizing every named struct
+// type it encounters so self-referential types (directly or through a
+// cycle of other named structs) emit a $ref to Definitions instead of
+// inlining forever.
+type Generator struct {
+	definitions spec.Definitions
+	named       map[reflect.Type]string
+}
+
+// New returns an empty Generator, ready to build one or more schemas that
+// share a single Definitions map.
+func New() *Generator {
+	return &Generator{
+		definitions: spec.Definitions{},
+		named:       map[reflect.Type]string{},
+	}
+}
+
+// SchemaOf generates a *spec.Schema describing t using a fresh Generator.
+// It is a convenience wrapper for the common case of generating a single
+// top-level schema; use New directly to share Definitions across several
+// related types.
+func SchemaOf(t reflect.Type) (*spec.Schema, error) {
+	g := New()
+	schema, err := g.SchemaOf(t)
+	if err != nil {
+		return nil, err
+	}
+	if len(g.definitions) > 0 {
+		schema.Definitions = g.definitions
+	}
+	return schema, nil
+}
+
+// SchemaOf generates a *spec.Schema describing t, registering any named
+// struct types it encounters along the way in g.Definitions. Unlike a
+// named struct type reached through a field (which is always emitted as a
+// $ref to its Definitions entry, including its first occurrence), the root
+// type t is always inlined in full here.
+func (g *Generator) SchemaOf(t reflect.Type) (*spec.Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return g.schemaFor(t, tagOptions{})
+	}
+	return g.rootStructSchema(t)
+}
+
+// rootStructSchema builds the full, inlined schema for a top-level struct
+// type, registering its name (if any) first so a field that refers back to
+// t resolves to a $ref instead of recursing forever.
+func (g *Generator) rootStructSchema(t reflect.Type) (*spec.Schema, error) {
+	name := qualifiedName(t)
+	if name != "" {
+		g.named[t] = name
+	}
+
+	schema, err := g.buildStructSchema(t)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		g.definitions[name] = *schema
+	}
+
+	return schema, nil
+}
+
+// Definitions returns the definitions accumulated across every SchemaOf
+// call made against g so far, keyed by package-qualified type name.
+func (g *Generator) Definitions() spec.Definitions {
+	return g.definitions
+}
+
+func (g *Generator) schemaFor(t reflect.Type, opts tagOptions) (*spec.Schema, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return g.structSchema(t)
+
+	case reflect.Slice, reflect.Array:
+		items, err := g.schemaFor(t.Elem(), tagOptions{})
+		if err != nil {
+			return nil, err
+		}
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"array"}
+		schema.Items = &spec.SchemaOrArray{Schema: items}
+		return schema, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("schemagen: map key type %s is not supported, only string keys are", t.Key())
+		}
+		value, err := g.schemaFor(t.Elem(), tagOptions{})
+		if err != nil {
+			return nil, err
+		}
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"object"}
+		schema.AdditionalProperties = &spec.SchemaOrBool{Schema: value}
+		return schema, nil
+
+	case reflect.String:
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"string"}
+		applyStringOptions(schema, opts)
+		return schema, nil
+
+	case reflect.Bool:
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"boolean"}
+		return schema, nil
+
+	case reflect.Float32, reflect.Float64:
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"number"}
+		applyNumberOptions(schema, opts)
+		return schema, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema := &spec.Schema{}
+		schema.Type = spec.StringOrArray{"integer"}
+		applyNumberOptions(schema, opts)
+		return schema, nil
+
+	case reflect.Interface:
+		// No schema constraints can be derived from interface{}; leave the
+		// schema wide open rather than erroring, since AdditionalProperties
+		// and oneOf branches legitimately decode into interface{} fields.
+		return &spec.Schema{}, nil
+
+	default:
+		return nil, fmt.Errorf("schemagen: unsupported kind %s for type %s", t.Kind(), t)
+	}
+}
+
+// structSchema generates the schema for a struct type, consulting and
+// populating g.named/g.definitions so a type referenced more than once
+// (including self-referentially) is only walked the first time.
+func (g *Generator) structSchema(t reflect.Type) (*spec.Schema, error) {
+	name := qualifiedName(t)
+	if name == "" {
+		return g.buildStructSchema(t)
+	}
+
+	if _, ok := g.named[t]; ok {
+		return refSchema(name), nil
+	}
+
+	// Register the name before walking fields, so a field that refers back
+	// to t (directly or through another named struct) resolves to a $ref
+	// instead of recursing forever.
+	g.named[t] = name
+
+	schema, err := g.buildStructSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	g.definitions[name] = *schema
+
+	return refSchema(name), nil
+}
+
+func refSchema(name string) *spec.Schema {
+	schema := &spec.Schema{}
+	schema.Ref = spec.MustCreateRef("#/definitions/" + name)
+	return schema
+}
+
+// qualifiedName returns the Definitions key for t: its package path and Go
+// name joined together, so two distinct types sharing a bare name (e.g.
+// pkgA.Item and pkgB.Item) get distinct entries instead of silently
+// overwriting one another. The package path's slashes are replaced with
+// dots, since a literal "/" would need RFC 6901 escaping everywhere this
+// name is used as a JSON Pointer fragment (e.g. "#/definitions/<name>").
+// Returns "" for unnamed types (e.g. anonymous structs), matching
+// t.Name()'s behaviour.
+func qualifiedName(t reflect.Type) string {
+	name := t.Name()
+	if name == "" {
+		return ""
+	}
+	pkgPath := strings.ReplaceAll(t.PkgPath(), "/", ".")
+	if pkgPath == "" {
+		return name
+	}
+	return pkgPath + "." + name
+}
+
+func (g *Generator) buildStructSchema(t reflect.Type) (*spec.Schema, error) {
+	schema := &spec.Schema{}
+	schema.Type = spec.StringOrArray{"object"}
+	schema.Properties = map[string]spec.Schema{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported field, not visible to encoding/json either
+			continue
+		}
+
+		jsonTag, hasJSONTag := field.Tag.Lookup("json")
+		tagBase, jsonOpts := splitJSONTag(jsonTag)
+		if tagBase == "-" && hasJSONTag {
+			continue
+		}
+
+		propName := tagBase
+		if !hasJSONTag {
+			propName = strings.ToLower(field.Name)
+		}
+
+		opts := parseTagOptions(field.Tag.Get("schema"))
+
+		propSchema, err := g.schemaFor(field.Type, opts)
+		if err != nil {
+			return nil, errors.Wrapf(err, "field %s.%s", t.Name(), field.Name)
+		}
+
+		schema.Properties[propName] = *propSchema
+
+		if !jsonOpts.omitempty && !opts.optional {
+			schema.Required = append(schema.Required, propName)
+		}
+	}
+
+	return schema, nil
+}
+
+// splitJSONTag splits a `json:"..."` tag value into its field name and
+// parsed options, the same way encoding/json does.
+func splitJSONTag(tag string) (name string, opts jsonTagOptions) {
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			opts.omitempty = true
+		}
+	}
+	return name, opts
+}
+
+type jsonTagOptions struct {
+	omitempty bool
+}
+
+// tagOptions carries the schema constraints parsed from a field's `schema`
+// tag, e.g. `schema:"minimum=1,maxLength=10,format=date-time,enum=a|b|c"`.
+type tagOptions struct {
+	minimum  *float64
+	maximum  *float64
+	minLen   *int64
+	maxLen   *int64
+	format   string
+	enum     []string
+	optional bool
+}
+
+func parseTagOptions(tag string) tagOptions {
+	var opts tagOptions
+	if tag == "" {
+		return opts
+	}
+
+	for _, part := range strings.Split(tag, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		key := kv[0]
+		var value string
+		hasValue := len(kv) == 2
+		if hasValue {
+			value = kv[1]
+		}
+
+		switch key {
+		case "optional":
+			opts.optional = true
+		case "minimum":
+			if v, err := strconv.ParseFloat(value, 64); hasValue && err == nil {
+				opts.minimum = &v
+			}
+		case "maximum":
+			if v, err := strconv.ParseFloat(value, 64); hasValue && err == nil {
+				opts.maximum = &v
+			}
+		case "minLength":
+			if v, err := strconv.ParseInt(value, 10, 64); hasValue && err == nil {
+				opts.minLen = &v
+			}
+		case "maxLength":
+			if v, err := strconv.ParseInt(value, 10, 64); hasValue && err == nil {
+				opts.maxLen = &v
+			}
+		case "format":
+			opts.format = value
+		case "enum":
+			opts.enum = strings.Split(value, "|")
+		}
+	}
+
+	return opts
+}
+
+func applyStringOptions(schema *spec.Schema, opts tagOptions) {
+	schema.MinLength = opts.minLen
+	schema.MaxLength = opts.maxLen
+	schema.Format = opts.format
+	if len(opts.enum) > 0 {
+		schema.Enum = make([]interface{}, len(opts.enum))
+		for i, v := range opts.enum {
+			schema.Enum[i] = v
+		}
+	}
+}
+
+func applyNumberOptions(schema *spec.Schema, opts tagOptions) {
+	schema.Minimum = opts.minimum
+	schema.Maximum = opts.maximum
+	schema.Format = opts.format
+}
@@ -0,0 +1,175 @@
+package schemagen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daemonl/schemastream/schemagen/internal/subpkg"
+)
+
+type genTestStruct struct {
+	Name     string  `json:"name"`
+	Age      int     `json:"age,omitempty"`
+	UnTagged bool
+	Tags     []string          `json:"tags"`
+	Extra    map[string]string `json:"extra"`
+}
+
+func TestSchemaOfStruct(t *testing.T) {
+	schema, err := SchemaOf(reflect.TypeOf(genTestStruct{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !schema.Type.Contains("object") {
+		t.Fatalf("expected an object schema, got %v", schema.Type)
+	}
+
+	nameProp, ok := schema.Properties["name"]
+	if !ok {
+		t.Fatal("expected a name property")
+	}
+	if !nameProp.Type.Contains("string") {
+		t.Errorf("expected name to be a string, got %v", nameProp.Type)
+	}
+
+	if _, ok := schema.Properties["age"]; !ok {
+		t.Fatal("expected an age property")
+	}
+
+	if _, ok := schema.Properties["untagged"]; !ok {
+		t.Fatal("expected untagged to fall back to its lower-cased field name")
+	}
+
+	tagsProp, ok := schema.Properties["tags"]
+	if !ok {
+		t.Fatal("expected a tags property")
+	}
+	if !tagsProp.Type.Contains("array") {
+		t.Errorf("expected tags to be an array, got %v", tagsProp.Type)
+	}
+
+	extraProp, ok := schema.Properties["extra"]
+	if !ok {
+		t.Fatal("expected an extra property")
+	}
+	if extraProp.AdditionalProperties == nil || extraProp.AdditionalProperties.Schema == nil {
+		t.Fatal("expected extra to have a typed additionalProperties schema")
+	}
+}
+
+func TestSchemaOfRequired(t *testing.T) {
+	schema, err := SchemaOf(reflect.TypeOf(genTestStruct{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	required := map[string]bool{}
+	for _, name := range schema.Required {
+		required[name] = true
+	}
+
+	if !required["name"] {
+		t.Errorf("expected name to be required")
+	}
+	if required["age"] {
+		t.Errorf("expected age to be omitted from required, it has omitempty")
+	}
+}
+
+type genTestNode struct {
+	Value    string        `json:"value"`
+	Children []genTestNode `json:"children"`
+}
+
+func TestSchemaOfCyclicalType(t *testing.T) {
+	schema, err := SchemaOf(reflect.TypeOf(genTestNode{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// The root type itself is inlined in full, not left as a bare $ref.
+	if !schema.Type.Contains("object") {
+		t.Fatalf("expected the root schema to be inlined, got %v", schema.Type)
+	}
+
+	childrenProp, ok := schema.Properties["children"]
+	if !ok {
+		t.Fatal("expected a children property")
+	}
+	if childrenProp.Items == nil || childrenProp.Items.Schema == nil {
+		t.Fatal("expected children to be an array of genTestNode")
+	}
+	if childrenProp.Items.Schema.Ref.String() == "" {
+		t.Fatal("expected the self-reference to be a $ref, not an inlined copy")
+	}
+
+	name := qualifiedName(reflect.TypeOf(genTestNode{}))
+	if _, ok := schema.Definitions[name]; !ok {
+		t.Fatalf("expected %s to be registered as a definition, for the $ref above to resolve against", name)
+	}
+}
+
+func TestSchemaOfPackageQualifiedNames(t *testing.T) {
+	type Item struct {
+		Name string `json:"name"`
+	}
+
+	a := reflect.TypeOf(Item{})
+	b := reflect.TypeOf(subpkg.Item{})
+
+	if a.Name() != b.Name() {
+		t.Fatalf("test setup: expected both types to share the bare name %q, got %q and %q", a.Name(), a.Name(), b.Name())
+	}
+
+	nameA := qualifiedName(a)
+	nameB := qualifiedName(b)
+	if nameA == nameB {
+		t.Fatalf("expected distinct package-qualified names for same-named types in different packages, got %q for both", nameA)
+	}
+
+	type container struct {
+		Local  Item        `json:"local"`
+		Remote subpkg.Item `json:"remote"`
+	}
+
+	schema, err := SchemaOf(reflect.TypeOf(container{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	localDef, ok := schema.Definitions[nameA]
+	if !ok {
+		t.Fatalf("expected a definition for the local Item at %q", nameA)
+	}
+	if _, ok := localDef.Properties["name"]; !ok {
+		t.Fatal("expected the local Item's definition to keep its own name property")
+	}
+
+	remoteDef, ok := schema.Definitions[nameB]
+	if !ok {
+		t.Fatalf("expected a definition for subpkg.Item at %q", nameB)
+	}
+	if _, ok := remoteDef.Properties["count"]; !ok {
+		t.Fatal("expected subpkg.Item's definition to keep its own count property, not be overwritten by the local Item")
+	}
+}
+
+func TestSchemaOfTagOptions(t *testing.T) {
+	type tagged struct {
+		Code string `json:"code" schema:"enum=a|b|c,maxLength=1"`
+	}
+
+	schema, err := SchemaOf(reflect.TypeOf(tagged{}))
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	codeProp := schema.Properties["code"]
+	if len(codeProp.Enum) != 3 {
+		t.Fatalf("expected 3 enum values, got %v", codeProp.Enum)
+	}
+	if codeProp.MaxLength == nil || *codeProp.MaxLength != 1 {
+		t.Fatalf("expected maxLength 1, got %v", codeProp.MaxLength)
+	}
+}
@@ -1,11 +1,14 @@
 package schemastream
 
 import (
+	"bytes"
 	"encoding"
 	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/go-openapi/spec"
@@ -45,15 +48,77 @@ func (d *Decoder) nextToken() (json.Token, error) {
 }
 
 type Baton struct {
-	schema *spec.Schema
-	into   reflect.Value
-	path   []string
+	schema      *spec.Schema
+	into        reflect.Value
+	path        []string
+	stream      map[string]StreamHandler
+	resolver    *SchemaResolver
+	types       map[string]reflect.Type
+	maxDepth    int
+	unknownMode UnknownPropertyMode
+	logger      Logger
+}
+
+// resolveBranch resolves a oneOf/anyOf/allOf branch schema's $ref the same
+// way baton.resolveSchema does, tolerating a nil resolver.
+func (b Baton) resolveBranch(s *spec.Schema) (*spec.Schema, error) {
+	if b.resolver == nil {
+		return s, nil
+	}
+	return b.resolver.Resolve(s)
+}
+
+// logf emits optional trace output via b.logger; a nil logger is a no-op.
+func (b Baton) logf(format string, args ...interface{}) {
+	if b.logger == nil {
+		return
+	}
+	b.logger.Printf(format, args...)
+}
+
+// resolveSchema follows baton.schema's $ref, if any, via baton.resolver.
+func (b Baton) resolveSchema() (*spec.Schema, error) {
+	if b.resolver == nil || b.schema == nil {
+		return b.schema, nil
+	}
+	return b.resolver.Resolve(b.schema)
+}
+
+// StreamHandler decodes each element of a streamed array into a fresh value
+// of Type, validates it against the array's item schema, and passes it to
+// Fn. The value is never appended to a slice, so arrays registered this way
+// can be arbitrarily large without growing memory.
+type StreamHandler struct {
+	Type reflect.Type
+	Fn   func(value reflect.Value) error
+}
+
+// streamHandlerFor returns the StreamHandler registered for the array at
+// baton's current path, if any.
+func streamHandlerFor(baton Baton) (StreamHandler, bool) {
+	if baton.stream == nil {
+		return StreamHandler{}, false
+	}
+	handler, ok := baton.stream[strings.Join(baton.path, ".")]
+	return handler, ok
 }
 
 func ValidateParse(reader io.Reader, into interface{}, schema *spec.Schema) error {
-	jsonDecoder := json.NewDecoder(reader)
-	jsonDecoder.UseNumber()
+	return ValidateParseOptions(reader, into, schema, Options{})
+}
+
+// ValidateParseWithTypes is ValidateParse, but also registers the concrete
+// Go types to allocate for an interface{} destination when decoding a
+// discriminated oneOf/anyOf schema: types is keyed by the discriminator
+// value (the name of the matching branch's $ref).
+func ValidateParseWithTypes(reader io.Reader, into interface{}, schema *spec.Schema, types map[string]reflect.Type) error {
+	return ValidateParseOptions(reader, into, schema, Options{Types: types})
+}
 
+// ValidateParseOptions is ValidateParse with explicit control over the
+// depth limit, unknown-property handling, discriminator types, and tracing.
+// See Options for defaults.
+func ValidateParseOptions(reader io.Reader, into interface{}, schema *spec.Schema, opts Options) error {
 	rv := reflect.ValueOf(into)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &json.InvalidUnmarshalError{
@@ -61,29 +126,85 @@ func ValidateParse(reader io.Reader, into interface{}, schema *spec.Schema) erro
 		}
 	}
 
+	if err := validateStructTags(rv.Type()); err != nil {
+		return err
+	}
+
+	jsonDecoder := json.NewDecoder(reader)
+	jsonDecoder.UseNumber()
+
 	decoder := &Decoder{
 		jsonDecoder: jsonDecoder,
 	}
 	baton := Baton{
-		schema: schema,
-		into:   rv.Elem(),
-		path:   []string{},
+		schema:      schema,
+		into:        rv.Elem(),
+		path:        []string{},
+		resolver:    NewResolver(schema, nil),
+		types:       opts.Types,
+		maxDepth:    maxDepthOrDefault(opts.MaxDepth),
+		unknownMode: opts.UnknownProperty,
+		logger:      opts.Logger,
 	}
 	return decodeAnything(decoder, baton)
 
 }
 
-func printToken(prefix string, token json.Token) {
-	fmt.Printf("Token %s: %T %v\n", prefix, token, token)
+// ValidateParseStream decodes reader against schema like ValidateParse, but
+// takes no destination struct. Instead, handlers registers a callback per
+// array path (dot-separated, matching the property nesting, e.g. "items" or
+// "data.rows"): when decodeArray reaches a registered path, each element is
+// decoded into a fresh value of the handler's Type, validated against
+// schema.Items, and passed to Fn, rather than being accumulated into a
+// slice. This keeps memory bounded when streaming very large arrays.
+func ValidateParseStream(reader io.Reader, schema *spec.Schema, handlers map[string]StreamHandler) error {
+	return ValidateParseStreamOptions(reader, schema, handlers, Options{})
+}
+
+// ValidateParseStreamOptions is ValidateParseStream with explicit control
+// over the depth limit, unknown-property handling, and tracing. See Options
+// for defaults.
+func ValidateParseStreamOptions(reader io.Reader, schema *spec.Schema, handlers map[string]StreamHandler, opts Options) error {
+	jsonDecoder := json.NewDecoder(reader)
+	jsonDecoder.UseNumber()
+
+	decoder := &Decoder{
+		jsonDecoder: jsonDecoder,
+	}
+	baton := Baton{
+		schema:      schema,
+		path:        []string{},
+		stream:      handlers,
+		resolver:    NewResolver(schema, nil),
+		types:       opts.Types,
+		maxDepth:    maxDepthOrDefault(opts.MaxDepth),
+		unknownMode: opts.UnknownProperty,
+		logger:      opts.Logger,
+	}
+	return decodeAnything(decoder, baton)
 }
 
 func decodeAnything(decoder *Decoder, baton Baton) error {
+	if maxDepth := maxDepthOrDefault(baton.maxDepth); len(baton.path) > maxDepth {
+		return &MaxDepthError{MaxDepth: maxDepth, Pointer: "/" + strings.Join(baton.path, "/")}
+	}
+
+	schema, err := baton.resolveSchema()
+	if err != nil {
+		return err
+	}
+	baton.schema = schema
+
+	if schema != nil && (len(schema.OneOf) > 0 || len(schema.AnyOf) > 0 || len(schema.AllOf) > 0) {
+		return decodeComposite(decoder, baton)
+	}
+
 	token, err := decoder.Token()
 	if err != nil {
 		return err
 	}
 
-	printToken(strings.Join(baton.path, ","), token)
+	baton.logf("token %s: %T %v", strings.Join(baton.path, ","), token, token)
 
 	if tokenVal, ok := token.(json.Delim); ok {
 		switch tokenVal {
@@ -115,17 +236,30 @@ func decodeValue(decoder *Decoder, baton Baton, token json.Token) error {
 
 	jsonUnmarshaller, textUnmarshaller, into := indirect(baton.into, token == nil)
 
-	_ = jsonUnmarshaller
-	_ = textUnmarshaller
-
-	intoKind := into.Kind()
-
-	fmt.Printf("Decode %v into %v -> %s\n", token, intoKind.String(), baton.schema.Type)
-
 	if err := validate.AgainstSchema(baton.schema, token, strfmt.Default); err != nil {
 		return err
 	}
 
+	if jsonUnmarshaller != nil {
+		raw, err := json.Marshal(token)
+		if err != nil {
+			return err
+		}
+		return jsonUnmarshaller.UnmarshalJSON(raw)
+	}
+
+	if textUnmarshaller != nil {
+		strVal, ok := token.(string)
+		if !ok {
+			return fmt.Errorf("Cannot unmarshal %T into TextUnmarshaler", token)
+		}
+		return textUnmarshaller.UnmarshalText([]byte(strVal))
+	}
+
+	intoKind := into.Kind()
+
+	baton.logf("decode %v into %s -> %s", token, intoKind.String(), baton.schema.Type)
+
 	switch tokenVal := token.(type) {
 
 	case string:
@@ -176,15 +310,214 @@ func decodeValue(decoder *Decoder, baton Baton, token json.Token) error {
 	return nil
 }
 
+// decodeComposite handles schemas using oneOf/anyOf/allOf composition. The
+// upcoming JSON value is buffered once into a generic Go value, the winning
+// branch (or, for allOf, a schema merged from every branch) is selected
+// against it, and then decoded for real from a fresh in-memory Decoder over
+// the buffered bytes.
+func decodeComposite(decoder *Decoder, baton Baton) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	value, err := captureValue(decoder, token)
+	if err != nil {
+		return err
+	}
+
+	branchSchema, into, err := selectBranch(baton, value)
+	if err != nil {
+		return errors.Wrapf(err, "At path %s", strings.Join(baton.path, "."))
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	subJSONDecoder := json.NewDecoder(bytes.NewReader(raw))
+	subJSONDecoder.UseNumber()
+
+	return decodeAnything(&Decoder{jsonDecoder: subJSONDecoder}, Baton{
+		schema:      branchSchema,
+		into:        into,
+		path:        baton.path,
+		stream:      baton.stream,
+		resolver:    baton.resolver,
+		types:       baton.types,
+		maxDepth:    baton.maxDepth,
+		unknownMode: baton.unknownMode,
+		logger:      baton.logger,
+	})
+}
+
+// selectBranch picks the oneOf/anyOf/allOf branch that matches value, and
+// the destination to decode it into. A discriminator property (Swagger
+// 2.0 style: its value names the matching branch's $ref) skips trial
+// validation, and for an interface{} destination allocates a concrete
+// struct from baton.types keyed by the discriminator value. Without a
+// discriminator, each branch is validated against value in turn and the
+// first match wins. allOf instead requires every branch to validate, and
+// decodes against a schema merging all of their properties.
+func selectBranch(baton Baton, value interface{}) (*spec.Schema, reflect.Value, error) {
+	schema := baton.schema
+
+	if len(schema.AllOf) > 0 {
+		merged, err := mergeAllOf(baton, schema.AllOf, value)
+		if err != nil {
+			return nil, reflect.Value{}, err
+		}
+		return merged, baton.into, nil
+	}
+
+	branches := schema.OneOf
+	if len(branches) == 0 {
+		branches = schema.AnyOf
+	}
+
+	if schema.Discriminator != "" {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, reflect.Value{}, fmt.Errorf("discriminator %q requires an object value", schema.Discriminator)
+		}
+		discVal, _ := obj[schema.Discriminator].(string)
+		if discVal == "" {
+			return nil, reflect.Value{}, fmt.Errorf("missing discriminator property %s", schema.Discriminator)
+		}
+
+		branch, err := discriminatedBranch(baton, branches, discVal)
+		if err != nil {
+			return nil, reflect.Value{}, err
+		}
+		into, err := allocateBranchDestination(baton, discVal)
+		if err != nil {
+			return nil, reflect.Value{}, err
+		}
+		return branch, into, nil
+	}
+
+	for i := range branches {
+		branch, err := baton.resolveBranch(&branches[i])
+		if err != nil {
+			return nil, reflect.Value{}, err
+		}
+		if err := validate.AgainstSchema(branch, value, strfmt.Default); err == nil {
+			return branch, baton.into, nil
+		}
+	}
+	return nil, reflect.Value{}, fmt.Errorf("value matches no branch of oneOf/anyOf")
+}
+
+// discriminatedBranch finds the branch whose $ref name equals discVal.
+func discriminatedBranch(baton Baton, branches []spec.Schema, discVal string) (*spec.Schema, error) {
+	for i := range branches {
+		if refName(branches[i].Ref) == discVal {
+			return baton.resolveBranch(&branches[i])
+		}
+	}
+	return nil, fmt.Errorf("discriminator value %q matches no branch", discVal)
+}
+
+// refName returns the last path segment of a $ref, e.g. "Dog" for
+// "#/definitions/Dog".
+func refName(ref spec.Ref) string {
+	s := ref.String()
+	if idx := strings.LastIndex(s, "/"); idx >= 0 {
+		return s[idx+1:]
+	}
+	return s
+}
+
+// allocateBranchDestination allocates a concrete struct for an interface{}
+// destination, using the Go type registered under discVal in baton.types.
+// Any other destination kind is returned unchanged.
+func allocateBranchDestination(baton Baton, discVal string) (reflect.Value, error) {
+	if !baton.into.IsValid() || baton.into.Kind() != reflect.Interface {
+		return baton.into, nil
+	}
+	goType, ok := baton.types[discVal]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("no type registered for discriminator value %q", discVal)
+	}
+	concrete := reflect.New(goType)
+	baton.into.Set(concrete)
+	return concrete, nil
+}
+
+// mergeAllOf validates value against every branch (all must pass) and
+// returns a schema synthesized from their combined properties, required
+// fields and additionalProperties, so decodeObject can resolve fields
+// declared across branches as if they were a single schema.
+func mergeAllOf(baton Baton, branches []spec.Schema, value interface{}) (*spec.Schema, error) {
+	merged := &spec.Schema{}
+	merged.Type = spec.StringOrArray{"object"}
+	merged.Properties = map[string]spec.Schema{}
+
+	for i := range branches {
+		branch, err := baton.resolveBranch(&branches[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := validate.AgainstSchema(branch, value, strfmt.Default); err != nil {
+			return nil, errors.Wrapf(err, "allOf branch %d", i)
+		}
+
+		if len(branch.Type) > 0 {
+			merged.Type = branch.Type
+		}
+		for name, propSchema := range branch.Properties {
+			merged.Properties[name] = propSchema
+		}
+		merged.Required = append(merged.Required, branch.Required...)
+		if branch.AdditionalProperties != nil {
+			merged.AdditionalProperties = branch.AdditionalProperties
+		}
+	}
+
+	return merged, nil
+}
+
 func decodeArray(decoder *Decoder, baton Baton) error {
+	if baton.schema == nil {
+		return skipArray(decoder)
+	}
+
 	if !baton.schema.Type.Contains("array") {
 		return fmt.Errorf("Not expecting an array")
 	}
 
-	itemType := baton.into.Type().Elem()
-	fmt.Printf("Item Type for Array: %s\n", itemType.String())
+	if handler, ok := streamHandlerFor(baton); ok {
+		return decodeArrayStream(decoder, baton, handler)
+	}
+
+	into := baton.into
+	if into.IsValid() {
+		jsonUnmarshaller, _, indirected := indirect(into, false)
+
+		if jsonUnmarshaller != nil {
+			body, err := captureArrayBody(decoder)
+			if err != nil {
+				return err
+			}
+			if err := validate.AgainstSchema(baton.schema, body, strfmt.Default); err != nil {
+				return err
+			}
+			raw, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			return jsonUnmarshaller.UnmarshalJSON(raw)
+		}
+
+		into = indirected
+	}
+
+	var itemType reflect.Type
+	if into.IsValid() {
+		itemType = into.Type().Elem()
+	}
 
-	arrayValue := baton.into
+	arrayValue := into
 	idx := 0
 	for {
 		// Don't consume the token
@@ -193,54 +526,148 @@ func decodeArray(decoder *Decoder, baton Baton) error {
 			return err
 		}
 		if keyToken == json.Delim(']') {
-			baton.into.Set(arrayValue)
+			if into.IsValid() {
+				into.Set(arrayValue)
+			}
 			// discard Next
 			decoder.Token()
 			return nil
 		}
 
-		field := reflect.New(itemType)
+		var field reflect.Value
+		if itemType != nil {
+			field = reflect.New(itemType)
+		}
 		fieldSchema := baton.schema.Items.Schema
 		fieldPath := append(baton.path, fmt.Sprintf("%d", idx))
 
 		if err := decodeAnything(decoder, Baton{
-			into:   field,
-			schema: fieldSchema,
-			path:   fieldPath,
+			into:        field,
+			schema:      fieldSchema,
+			path:        fieldPath,
+			stream:      baton.stream,
+			resolver:    baton.resolver,
+			types:       baton.types,
+			maxDepth:    baton.maxDepth,
+			unknownMode: baton.unknownMode,
+			logger:      baton.logger,
 		}); err != nil {
 			return errors.Wrapf(err, "At path %s", strings.Join(fieldPath, "."))
 		}
 
-		arrayValue = reflect.Append(arrayValue, field.Elem())
+		if itemType != nil {
+			arrayValue = reflect.Append(arrayValue, field.Elem())
+		}
 		idx++
 	}
 
 }
 
+// decodeArrayStream is the streaming counterpart of decodeArray's slice
+// building loop: it never grows a slice, instead decoding each element into
+// a fresh handler.Type value and handing it to handler.Fn before moving on
+// to the next element.
+func decodeArrayStream(decoder *Decoder, baton Baton, handler StreamHandler) error {
+	idx := 0
+	for {
+		keyToken, err := decoder.nextToken()
+		if err != nil {
+			return err
+		}
+		if keyToken == json.Delim(']') {
+			decoder.Token()
+			return nil
+		}
+
+		elem := reflect.New(handler.Type)
+		fieldPath := append(baton.path, fmt.Sprintf("%d", idx))
+
+		if err := decodeAnything(decoder, Baton{
+			into:        elem,
+			schema:      baton.schema.Items.Schema,
+			path:        fieldPath,
+			stream:      baton.stream,
+			resolver:    baton.resolver,
+			types:       baton.types,
+			maxDepth:    baton.maxDepth,
+			unknownMode: baton.unknownMode,
+			logger:      baton.logger,
+		}); err != nil {
+			return errors.Wrapf(err, "At path %s", strings.Join(fieldPath, "."))
+		}
+
+		if err := handler.Fn(elem.Elem()); err != nil {
+			return errors.Wrapf(err, "At path %s", strings.Join(fieldPath, "."))
+		}
+		idx++
+	}
+}
+
 func decodeObject(decoder *Decoder, baton Baton) error {
-	fmt.Printf("Decode Object into %s\n", baton.into.Type().Name())
+	if baton.schema == nil {
+		_, err := captureObjectBody(decoder)
+		return err
+	}
+
 	if !baton.schema.Type.Contains("object") {
 		return fmt.Errorf("Not expecting an object")
 	}
 
-	jsonUnmarshaller, textUnmarshaller, into := indirect(baton.into, false)
-
-	_ = jsonUnmarshaller
-	_ = textUnmarshaller
-
 	fieldsByTag := map[string]reflect.Value{}
 	backupFieldsByTag := map[string]reflect.Value{}
 
-	for idx := 0; idx < into.NumField(); idx++ {
-		field := into.Field(idx)
-		fieldType := into.Type().Field(idx)
-		jsonTag, ok := fieldType.Tag.Lookup("json")
-		if ok {
-			tagBase := strings.Split(jsonTag, ",")[0]
-			fieldsByTag[tagBase] = field
-		} else {
-			backupFieldsByTag[strings.ToLower(fieldType.Name)] = field
+	var into reflect.Value
+	var mapValueType reflect.Type
+	if baton.into.IsValid() {
+		jsonUnmarshaller, _, indirected := indirect(baton.into, false)
+
+		if jsonUnmarshaller != nil {
+			body, err := captureObjectBody(decoder)
+			if err != nil {
+				return err
+			}
+			if err := validate.AgainstSchema(baton.schema, body, strfmt.Default); err != nil {
+				return err
+			}
+			raw, err := json.Marshal(body)
+			if err != nil {
+				return err
+			}
+			return jsonUnmarshaller.UnmarshalJSON(raw)
 		}
+
+		into = indirected
+
+		switch into.Kind() {
+		case reflect.Struct:
+			baton.logf("decode object into %s", into.Type().Name())
+
+			for idx := 0; idx < into.NumField(); idx++ {
+				field := into.Field(idx)
+				fieldType := into.Type().Field(idx)
+				jsonTag, ok := fieldType.Tag.Lookup("json")
+				if ok {
+					tagBase := strings.Split(jsonTag, ",")[0]
+					fieldsByTag[tagBase] = field
+				} else {
+					backupFieldsByTag[strings.ToLower(fieldType.Name)] = field
+				}
+			}
+
+		case reflect.Map:
+			mapValueType = into.Type().Elem()
+			if into.IsNil() {
+				into.Set(reflect.MakeMap(into.Type()))
+			}
+
+		default:
+			return fmt.Errorf("Cannot decode a JSON object into %s", into.Kind().String())
+		}
+	}
+
+	patterns, err := compilePatternProperties(baton.schema)
+	if err != nil {
+		return err
 	}
 
 	for {
@@ -256,33 +683,207 @@ func decodeObject(decoder *Decoder, baton Baton) error {
 			return fmt.Errorf("Expected a string got %v", keyToken)
 		}
 
-		field, ok := fieldsByTag[keyName]
-		if !ok {
-			field, ok = backupFieldsByTag[strings.ToLower(keyName)]
-			if !ok {
-				// TODO: Skip?
+		fieldSchema, allowed := propertySchema(baton.schema, patterns, keyName)
+		if !allowed {
+			switch baton.unknownMode {
+			case WarnOnUnknown:
+				baton.logf("skipping unknown property %s", jsonPointer(baton.path, keyName))
+			case SkipUnknown:
+			default:
+				return &UnknownPropertyError{Pointer: jsonPointer(baton.path, keyName)}
 			}
 		}
-		fieldSchemaVal, ok := baton.schema.Properties[keyName]
-		fieldSchema := &fieldSchemaVal
-		if !ok {
-			if baton.schema.AdditionalProperties == nil || !baton.schema.AdditionalProperties.Allows {
-				return fmt.Errorf("Unknown Property %s", keyName)
+
+		var field reflect.Value
+		switch {
+		case into.Kind() == reflect.Struct:
+			field, ok = fieldsByTag[keyName]
+			if !ok {
+				field, ok = backupFieldsByTag[strings.ToLower(keyName)]
+				if !ok {
+					// TODO: Skip?
+				}
 			}
-			fieldSchema = nil
+		case into.Kind() == reflect.Map && fieldSchema != nil:
+			// A nil fieldSchema (bare additionalProperties: true, or an
+			// unmatched key under WarnOnUnknown/SkipUnknown) has nothing to
+			// decode the value into, so leave field invalid below rather
+			// than writing a zero-value entry for keyName.
+			field = reflect.New(mapValueType)
 		}
 
 		fieldPath := append(baton.path, keyName)
 		if err := decodeAnything(decoder, Baton{
-			into:   field,
-			schema: fieldSchema,
-			path:   fieldPath,
+			into:        field,
+			schema:      fieldSchema,
+			path:        fieldPath,
+			stream:      baton.stream,
+			resolver:    baton.resolver,
+			types:       baton.types,
+			maxDepth:    baton.maxDepth,
+			unknownMode: baton.unknownMode,
+			logger:      baton.logger,
 		}); err != nil {
 			return errors.Wrapf(err, "At path %s", strings.Join(fieldPath, "."))
 		}
 
+		if into.Kind() == reflect.Map && fieldSchema != nil && field.IsValid() {
+			into.SetMapIndex(reflect.ValueOf(keyName), field.Elem())
+		}
+	}
+
+}
+
+// patternSchema pairs a compiled patternProperties regular expression with
+// the schema its matching keys should be decoded against.
+type patternSchema struct {
+	re     *regexp.Regexp
+	schema *spec.Schema
+}
+
+// compilePatternProperties compiles schema.PatternProperties once so
+// decodeObject doesn't recompile a pattern per key. The result is sorted by
+// pattern string, so the order in which patterns are tried is stable.
+func compilePatternProperties(schema *spec.Schema) ([]patternSchema, error) {
+	if len(schema.PatternProperties) == 0 {
+		return nil, nil
+	}
+
+	names := make([]string, 0, len(schema.PatternProperties))
+	for pattern := range schema.PatternProperties {
+		names = append(names, pattern)
+	}
+	sort.Strings(names)
+
+	patterns := make([]patternSchema, 0, len(schema.PatternProperties))
+	for _, pattern := range names {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "compiling patternProperties %q", pattern)
+		}
+		propSchema := schema.PatternProperties[pattern]
+		patterns = append(patterns, patternSchema{re: re, schema: &propSchema})
+	}
+	return patterns, nil
+}
+
+// propertySchema resolves the schema to validate and decode keyName
+// against: an explicit entry in schema.Properties takes precedence, then
+// the first matching patternProperties entry (in sorted pattern order), then
+// schema.AdditionalProperties. allowed is false when keyName is none of
+// the above and additionalProperties is unset or false.
+func propertySchema(schema *spec.Schema, patterns []patternSchema, keyName string) (fieldSchema *spec.Schema, allowed bool) {
+	if propSchema, ok := schema.Properties[keyName]; ok {
+		return &propSchema, true
+	}
+
+	for _, pattern := range patterns {
+		if pattern.re.MatchString(keyName) {
+			return pattern.schema, true
+		}
+	}
+
+	if schema.AdditionalProperties != nil {
+		if schema.AdditionalProperties.Schema != nil {
+			return schema.AdditionalProperties.Schema, true
+		}
+		if schema.AdditionalProperties.Allows {
+			return nil, true
+		}
 	}
 
+	return nil, false
+}
+
+// skipArray discards the remaining elements of a JSON array, whose opening
+// '[' has already been consumed, without building a return value. It is used
+// when decodeArray has no schema to validate or decode elements against.
+func skipArray(decoder *Decoder) error {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if token == json.Delim(']') {
+			return nil
+		}
+		if _, err := captureValue(decoder, token); err != nil {
+			return err
+		}
+	}
+}
+
+// captureObjectBody reconstructs the remaining body of a JSON object, whose
+// opening '{' has already been consumed, into a generic map. It is used to
+// hand a json.Unmarshaler the object re-encoded by json.Marshal (compact,
+// keys sorted; not the original input bytes), and to validate the object as
+// a whole before dispatching to it.
+func captureObjectBody(decoder *Decoder) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+	for {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if keyToken == json.Delim('}') {
+			return obj, nil
+		}
+		keyName, ok := keyToken.(string)
+		if !ok {
+			return nil, fmt.Errorf("Expected a string got %v", keyToken)
+		}
+		valToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		val, err := captureValue(decoder, valToken)
+		if err != nil {
+			return nil, err
+		}
+		obj[keyName] = val
+	}
+}
+
+// captureValue reconstructs the JSON value represented by token into a
+// generic Go value (map[string]interface{}, []interface{}, or scalar),
+// consuming whatever further tokens make up that value from decoder.
+func captureValue(decoder *Decoder, token json.Token) (interface{}, error) {
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		return captureObjectBody(decoder)
+	case '[':
+		return captureArrayBody(decoder)
+	default:
+		return nil, fmt.Errorf("Unknown Token %s", delim)
+	}
+}
+
+// captureArrayBody reconstructs the remaining elements of a JSON array,
+// whose opening '[' has already been consumed, into a generic slice. It is
+// used to hand a json.Unmarshaler the array re-encoded by json.Marshal
+// (compact; not the original input bytes), and to validate the array as a
+// whole before dispatching to it.
+func captureArrayBody(decoder *Decoder) ([]interface{}, error) {
+	arr := []interface{}{}
+	for {
+		itemToken, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if itemToken == json.Delim(']') {
+			return arr, nil
+		}
+		val, err := captureValue(decoder, itemToken)
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+	}
 }
 
 ///////////////////////////////////
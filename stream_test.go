@@ -2,10 +2,12 @@ package schemastream
 
 import (
 	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
 )
 
 func buildSchema(data string) *spec.Schema {
@@ -162,3 +164,353 @@ func TestArray(t *testing.T) {
 	}
 
 }
+
+func TestValidateParseStream(t *testing.T) {
+	var got []string
+
+	err := ValidateParseStream(strings.NewReader(`
+		{"items": ["a", "b", "c"]}
+	`), buildSchema(`{
+		"type": "object",
+		"properties": {
+			"items": {
+				"type": "array",
+				"items": { "type": "string" }
+			}
+		}
+	}`), map[string]StreamHandler{
+		"items": {
+			Type: reflect.TypeOf(""),
+			Fn: func(value reflect.Value) error {
+				got = append(got, value.String())
+				return nil
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("got %v", got)
+	}
+}
+
+type textUnmarshalerValue struct {
+	Raw string
+}
+
+func (v *textUnmarshalerValue) UnmarshalText(data []byte) error {
+	v.Raw = string(data)
+	return nil
+}
+
+func TestUnmarshalTextDispatch(t *testing.T) {
+	into := struct {
+		Value textUnmarshalerValue `json:"value"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"value": { "type": "string" }
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"value":"hello"}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if into.Value.Raw != "hello" {
+		t.Fatalf("got %q", into.Value.Raw)
+	}
+}
+
+// TestUnmarshalJSONDispatchArray guards against a json.Unmarshaler
+// destination (such as json.RawMessage) whose JSON value happens to be an
+// array: decodeArray must buffer and dispatch to UnmarshalJSON the same way
+// decodeObject and decodeValue do, rather than falling into the generic
+// element-by-element array decode.
+func TestUnmarshalJSONDispatchArray(t *testing.T) {
+	into := struct {
+		Raw json.RawMessage `json:"raw"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"raw": {
+				"type": "array",
+				"items": { "type": "integer" }
+			}
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"raw":[1,2,3]}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if string(into.Raw) != "[1,2,3]" {
+		t.Fatalf("got %s", string(into.Raw))
+	}
+}
+
+func TestRefResolution(t *testing.T) {
+	into := struct {
+		Pet struct {
+			Name string `json:"name"`
+		} `json:"pet"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"definitions": {
+			"Pet": {
+				"type": "object",
+				"properties": { "name": { "type": "string" } }
+			}
+		},
+		"properties": {
+			"pet": { "$ref": "#/definitions/Pet" }
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"pet":{"name":"fido"}}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if into.Pet.Name != "fido" {
+		t.Fatalf("got %q", into.Pet.Name)
+	}
+}
+
+func TestOneOfBranchSelection(t *testing.T) {
+	into := struct {
+		Value struct {
+			A string `json:"a"`
+			B string `json:"b"`
+		} `json:"value"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"value": {
+				"oneOf": [
+					{ "type": "object", "properties": { "a": { "type": "string" } }, "required": ["a"] },
+					{ "type": "object", "properties": { "b": { "type": "string" } }, "required": ["b"] }
+				]
+			}
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"value":{"a":"foo"}}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if into.Value.A != "foo" {
+		t.Fatalf("expected the first oneOf branch to be selected, got %q", into.Value.A)
+	}
+	if into.Value.B != "" {
+		t.Fatalf("did not expect the second branch's field to be set, got %q", into.Value.B)
+	}
+}
+
+type discriminatorCat struct {
+	Type string `json:"type"`
+	Meow string `json:"meow"`
+}
+
+type discriminatorDog struct {
+	Type string `json:"type"`
+	Bark string `json:"bark"`
+}
+
+func TestDiscriminatedOneOf(t *testing.T) {
+	into := struct {
+		Animal interface{} `json:"animal"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"definitions": {
+			"Cat": {
+				"type": "object",
+				"properties": { "type": { "type": "string" }, "meow": { "type": "string" } }
+			},
+			"Dog": {
+				"type": "object",
+				"properties": { "type": { "type": "string" }, "bark": { "type": "string" } }
+			}
+		},
+		"properties": {
+			"animal": {
+				"discriminator": "type",
+				"oneOf": [
+					{ "$ref": "#/definitions/Cat" },
+					{ "$ref": "#/definitions/Dog" }
+				]
+			}
+		}
+	}`)
+
+	types := map[string]reflect.Type{
+		"Cat": reflect.TypeOf(discriminatorCat{}),
+		"Dog": reflect.TypeOf(discriminatorDog{}),
+	}
+
+	err := ValidateParseWithTypes(strings.NewReader(`{"animal":{"type":"Cat","meow":"purr"}}`), &into, schema, types)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cat, ok := into.Animal.(*discriminatorCat)
+	if !ok {
+		t.Fatalf("expected a *discriminatorCat, got %T", into.Animal)
+	}
+	if cat.Meow != "purr" {
+		t.Fatalf("got %q", cat.Meow)
+	}
+}
+
+func TestTypedAdditionalProperties(t *testing.T) {
+	into := struct {
+		Extra map[string]string `json:"extra"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"extra": {
+				"type": "object",
+				"additionalProperties": { "type": "string" }
+			}
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"extra":{"a":"1","b":"2"}}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if into.Extra["a"] != "1" || into.Extra["b"] != "2" {
+		t.Fatalf("got %v", into.Extra)
+	}
+}
+
+func TestPatternProperties(t *testing.T) {
+	into := struct {
+		Extra map[string]string `json:"extra"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"extra": {
+				"type": "object",
+				"patternProperties": {
+					"^x_": { "type": "string" }
+				}
+			}
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"extra":{"x_foo":"bar"}}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if into.Extra["x_foo"] != "bar" {
+		t.Fatalf("got %v", into.Extra)
+	}
+}
+
+func TestBareAdditionalPropertiesOmitsUntypedMapEntries(t *testing.T) {
+	into := struct {
+		Extra map[string]string `json:"extra"`
+	}{}
+
+	schema := buildSchema(`{
+		"type": "object",
+		"properties": {
+			"extra": {
+				"type": "object",
+				"additionalProperties": true
+			}
+		}
+	}`)
+
+	if err := ValidateParse(strings.NewReader(`{"extra":{"foo":"bar"}}`), &into, schema); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, ok := into.Extra["foo"]; ok {
+		t.Fatalf("expected foo to be omitted, since additionalProperties:true has no schema to decode it against, got %v", into.Extra)
+	}
+}
+
+type depthTestNode struct {
+	Child *depthTestNode `json:"child"`
+}
+
+func TestMaxDepthExceeded(t *testing.T) {
+	schema := buildSchema(`{
+		"definitions": {
+			"Node": {
+				"type": "object",
+				"properties": { "child": { "$ref": "#/definitions/Node" } }
+			}
+		},
+		"$ref": "#/definitions/Node"
+	}`)
+
+	doc := "{}"
+	for i := 0; i < 8; i++ {
+		doc = `{"child":` + doc + `}`
+	}
+
+	into := depthTestNode{}
+	err := ValidateParseOptions(strings.NewReader(doc), &into, schema, Options{MaxDepth: 5})
+	if err == nil {
+		t.Fatal("expected a max depth error")
+	}
+	if _, ok := errors.Cause(err).(*MaxDepthError); !ok {
+		t.Fatalf("expected a *MaxDepthError, got %T: %v", err, err)
+	}
+}
+
+func TestUnknownPropertyModes(t *testing.T) {
+	schema := buildSchema(defaultSchema)
+
+	into := TestStruct{}
+	err := ValidateParseOptions(strings.NewReader(`{"string":"a","unexpected":"b"}`), &into, schema, Options{})
+	if err == nil {
+		t.Fatal("expected the default ErrorOnUnknown mode to reject an unknown property")
+	}
+	if _, ok := err.(*UnknownPropertyError); !ok {
+		t.Fatalf("expected a *UnknownPropertyError, got %T: %v", err, err)
+	}
+
+	into = TestStruct{}
+	err = ValidateParseOptions(strings.NewReader(`{"string":"a","unexpected":"b"}`), &into, schema, Options{UnknownProperty: SkipUnknown})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if into.String != "a" {
+		t.Fatalf("expected known properties to still decode under SkipUnknown, got %q", into.String)
+	}
+}
+
+func TestValidateStructTagsRejectsDuplicateTag(t *testing.T) {
+	// Built via reflect.StructOf rather than a literal struct, so the
+	// deliberately duplicate `json:"x"` tag doesn't trip go vet's static
+	// structtag check.
+	dupType := reflect.StructOf([]reflect.StructField{
+		{Name: "A", Type: reflect.TypeOf(""), Tag: `json:"x"`},
+		{Name: "B", Type: reflect.TypeOf(""), Tag: `json:"x"`},
+	})
+
+	into := reflect.New(dupType).Interface()
+	err := ValidateParse(strings.NewReader(`{}`), into, buildSchema(`{"type":"object","properties":{}}`))
+	if err == nil {
+		t.Fatal("expected a duplicate json tag to be rejected at ValidateParse entry")
+	}
+}